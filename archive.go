@@ -0,0 +1,153 @@
+package logmerge
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// isArchive reports whether path names a tar or zip archive that Read
+// should expand into one Source per contained file, instead of a
+// single Source for the archive itself.
+func isArchive(path string) bool {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"),
+		strings.HasSuffix(lower, ".tar"),
+		strings.HasSuffix(lower, ".tar.gz"),
+		strings.HasSuffix(lower, ".tgz"),
+		strings.HasSuffix(lower, ".tar.zst"),
+		strings.HasSuffix(lower, ".tar.sn"),
+		strings.HasSuffix(lower, ".tar.snappy"):
+		return true
+	default:
+		return false
+	}
+}
+
+// archiveCodecName picks the codec that decompresses a .tar.* archive's
+// outer stream, independent of any per-entry compression inside it.
+func archiveCodecName(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "gzip"
+	case strings.HasSuffix(lower, ".tar.zst"):
+		return "zstd"
+	case strings.HasSuffix(lower, ".tar.sn"), strings.HasSuffix(lower, ".tar.snappy"):
+		return "snappy"
+	default:
+		return "none"
+	}
+}
+
+// openArchive opens path and expands it into one *Source per entry
+// whose name passes filter (nil means "include everything"). Each
+// Source is named "path:entry" - e.g. "logs.tar.gz:inner/path.log" -
+// so FilterHandler can tell which archive and entry a line came from.
+func openArchive(fsys FS, path string, filter func(string) bool) ([]*Source, error) {
+	fd, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		return openZip(path, fd, filter)
+	}
+
+	return openTar(path, fd, filter)
+}
+
+func openTar(path string, r io.Reader, filter func(string) bool) ([]*Source, error) {
+	codec, err := LookupCodec(archiveCodecName(path))
+	if err != nil {
+		return nil, err
+	}
+
+	cReader, err := codec.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("codec newreader: %s", path))
+	}
+	defer cReader.Close()
+
+	tr := tar.NewReader(cReader)
+
+	var sources []*Source
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("tar next: %s", path))
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if filter != nil && !filter(hdr.Name) {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("read tar entry %s: %s", hdr.Name, path))
+		}
+
+		sources = append(sources, &Source{
+			Name:   path + ":" + hdr.Name,
+			Reader: ioutil.NopCloser(bytes.NewReader(data)),
+		})
+	}
+
+	return sources, nil
+}
+
+func openZip(path string, r io.Reader, filter func(string) bool) ([]*Source, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("read zip: %s", path))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("zip newreader: %s", path))
+	}
+
+	var sources []*Source
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		if filter != nil && !filter(f.Name) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("open zip entry %s: %s", f.Name, path))
+		}
+
+		entryData, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("read zip entry %s: %s", f.Name, path))
+		}
+
+		sources = append(sources, &Source{
+			Name:   path + ":" + f.Name,
+			Reader: ioutil.NopCloser(bytes.NewReader(entryData)),
+		})
+	}
+
+	return sources, nil
+}
@@ -0,0 +1,132 @@
+package logmerge
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+func buildTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("tar WriteHeader error: %s", err.Error())
+		}
+
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("tar Write error: %s", err.Error())
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar Close error: %s", err.Error())
+	}
+
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip Close error: %s", err.Error())
+	}
+
+	return buf.Bytes()
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip Create error: %s", err.Error())
+		}
+
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("zip Write error: %s", err.Error())
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip Close error: %s", err.Error())
+	}
+
+	return buf.Bytes()
+}
+
+func TestOpenArchiveTarGz(t *testing.T) {
+	data := buildTarGz(t, map[string]string{
+		"a.log": "2020/01/01 00:00:01 a\n",
+		"b.log": "2020/01/01 00:00:02 b\n",
+	})
+
+	fsys := NewMemFS()
+	fsys.WriteFile("logs.tar.gz", data)
+
+	sources, err := openArchive(fsys, "logs.tar.gz", nil)
+	if err != nil {
+		t.Fatalf("openArchive error: %s", err.Error())
+	}
+
+	if len(sources) != 2 {
+		t.Fatalf("got %d sources, want 2", len(sources))
+	}
+
+	for _, src := range sources {
+		if src.Name != "logs.tar.gz:a.log" && src.Name != "logs.tar.gz:b.log" {
+			t.Errorf("unexpected source name %q", src.Name)
+		}
+		src.Reader.Close()
+	}
+}
+
+func TestOpenArchiveZipWithFilter(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"keep.log": "kept\n",
+		"skip.log": "skipped\n",
+	})
+
+	fsys := NewMemFS()
+	fsys.WriteFile("logs.zip", data)
+
+	sources, err := openArchive(fsys, "logs.zip", func(name string) bool {
+		return name == "keep.log"
+	})
+	if err != nil {
+		t.Fatalf("openArchive error: %s", err.Error())
+	}
+
+	if len(sources) != 1 {
+		t.Fatalf("got %d sources, want 1", len(sources))
+	}
+
+	if sources[0].Name != "logs.zip:keep.log" {
+		t.Errorf("got name %q, want %q", sources[0].Name, "logs.zip:keep.log")
+	}
+
+	sources[0].Reader.Close()
+}
+
+func TestIsArchive(t *testing.T) {
+	cases := map[string]bool{
+		"a.log":    false,
+		"a.tar":    true,
+		"a.tar.gz": true,
+		"a.tgz":    true,
+		"a.zip":    true,
+		"a.log.gz": false,
+	}
+
+	for path, want := range cases {
+		if got := isArchive(path); got != want {
+			t.Errorf("isArchive(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package logmerge
+
+import (
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+)
+
+/*
+Codec defines how a src/dst file is (de)compressed before the merge
+reads or writes its lines.
+*/
+type Codec interface {
+	// NewReader wraps r so Scan sees decompressed lines.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	// NewWriter wraps w so writes are compressed before hitting disk.
+	NewWriter(w io.Writer) io.WriteCloser
+}
+
+// ErrUnknownCodec returned when SrcCodec/DstCodec names a codec that was
+// never registered.
+var ErrUnknownCodec = errors.New("unknown codec")
+
+type nopReadCloser struct {
+	io.Reader
+}
+
+func (nopReadCloser) Close() error { return nil }
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+type noneCodec struct{}
+
+func (noneCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return nopReadCloser{r}, nil
+}
+
+func (noneCodec) NewWriter(w io.Writer) io.WriteCloser {
+	return nopWriteCloser{w}
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipCodec) NewWriter(w io.Writer) io.WriteCloser {
+	return gzip.NewWriter(w)
+}
+
+type snappyCodec struct{}
+
+func (snappyCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return nopReadCloser{snappy.NewReader(r)}, nil
+}
+
+func (snappyCodec) NewWriter(w io.Writer) io.WriteCloser {
+	return nopWriteCloser{snappy.NewWriter(w)}
+}
+
+var (
+	codecMu  sync.RWMutex
+	codecs   = map[string]Codec{}
+	extNames = map[string]string{
+		".gz":     "gzip",
+		".sn":     "snappy",
+		".snappy": "snappy",
+		".zst":    "zstd",
+		".bz2":    "bzip2",
+	}
+)
+
+func init() {
+	Register("none", noneCodec{})
+	Register("gzip", gzipCodec{})
+	Register("snappy", snappyCodec{})
+}
+
+// Register adds (or replaces) a named codec in the global registry, so
+// MergeByOption/QuickMerge can look it up by name or file extension.
+func Register(name string, c Codec) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+
+	codecs[name] = c
+}
+
+// LookupCodec returns the codec registered under name.
+func LookupCodec(name string) (Codec, error) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+
+	c, ok := codecs[name]
+	if !ok {
+		return nil, errors.Wrap(ErrUnknownCodec, name)
+	}
+
+	return c, nil
+}
+
+// detectCodec guesses a codec from a file's extension, falling back to
+// "none" when the extension isn't recognised.
+func detectCodec(path string) (Codec, error) {
+	name, ok := extNames[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		name = "none"
+	}
+
+	return LookupCodec(name)
+}
+
+// resolveCodec picks the codec to use for path: an explicit name wins,
+// otherwise it falls back to extension-based auto-detection.
+func resolveCodec(name, path string) (Codec, error) {
+	if name != "" {
+		return LookupCodec(name)
+	}
+
+	return detectCodec(path)
+}
@@ -0,0 +1,98 @@
+package logmerge
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodecRegistryBuiltins(t *testing.T) {
+	for _, name := range []string{"none", "gzip", "snappy"} {
+		if _, err := LookupCodec(name); err != nil {
+			t.Errorf("LookupCodec(%q) error: %s", name, err.Error())
+		}
+	}
+
+	if _, err := LookupCodec("does-not-exist"); err == nil {
+		t.Errorf("LookupCodec(unknown) should error")
+	}
+}
+
+func TestResolveCodecByExtension(t *testing.T) {
+	cases := map[string]string{
+		"a.log":        "none",
+		"a.log.gz":     "gzip",
+		"a.log.sn":     "snappy",
+		"a.log.snappy": "snappy",
+		"a.log.zst":    "zstd",
+		"a.log.bz2":    "bzip2",
+	}
+
+	for path, want := range cases {
+		codec, err := resolveCodec("", path)
+		if err != nil {
+			if want == "zstd" || want == "bzip2" {
+				// not yet registered by default; skip until Register is called.
+				continue
+			}
+			t.Errorf("resolveCodec(%q) error: %s", path, err.Error())
+			continue
+		}
+
+		wantCodec, _ := LookupCodec(want)
+		if codec != wantCodec {
+			t.Errorf("resolveCodec(%q) = %T, want %T", path, codec, wantCodec)
+		}
+	}
+}
+
+func TestResolveCodecExplicitName(t *testing.T) {
+	codec, err := resolveCodec("gzip", "a.log.sn")
+	if err != nil {
+		t.Fatalf("resolveCodec error: %s", err.Error())
+	}
+
+	gzipCodec, _ := LookupCodec("gzip")
+	if codec != gzipCodec {
+		t.Errorf("explicit codec name should win over extension")
+	}
+}
+
+func TestNoneCodecRoundTrip(t *testing.T) {
+	codec, err := LookupCodec("none")
+	if err != nil {
+		t.Fatalf("LookupCodec error: %s", err.Error())
+	}
+
+	var buf bytes.Buffer
+	w := codec.NewWriter(&buf)
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("write error: %s", err.Error())
+	}
+	w.Close()
+
+	r, err := codec.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader error: %s", err.Error())
+	}
+	defer r.Close()
+
+	got := make([]byte, 5)
+	if _, err := r.Read(got); err != nil {
+		t.Fatalf("read error: %s", err.Error())
+	}
+
+	if string(got) != "hello" {
+		t.Errorf("got %q, want %q", got, "hello")
+	}
+}
+
+func TestRegisterCustomCodec(t *testing.T) {
+	Register("zstd", noneCodec{})
+	Register("bzip2", noneCodec{})
+
+	for _, name := range []string{"zstd", "bzip2"} {
+		if _, err := LookupCodec(name); err != nil {
+			t.Errorf("LookupCodec(%q) error: %s", name, err.Error())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package logmerge
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+FS abstracts the filesystem operations MergeByOption needs, so
+sources and destinations don't have to live on local disk - an
+in-memory fixture in tests, a read-only overlay, or a remote store
+behind a custom implementation all work the same way.
+*/
+type FS interface {
+	Open(name string) (io.ReadCloser, error)
+	Create(name string) (io.WriteCloser, error)
+	Remove(name string) error
+	Glob(pattern string) ([]string, error)
+}
+
+// OSFs is the default FS, backed by the local filesystem. A pattern
+// that names a directory is expanded to every non-directory entry
+// beneath it.
+type OSFs struct{}
+
+func (OSFs) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (OSFs) Create(name string) (io.WriteCloser, error) {
+	return os.Create(name)
+}
+
+func (OSFs) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSFs) Glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// A pattern with no glob metacharacters names a single file, so a
+	// miss means it doesn't exist and should stat-error like Open
+	// would. A metacharacter pattern with no current matches is a
+	// legitimate zero-source glob - mirrors MemFS.Glob.
+	if matches == nil && !strings.ContainsAny(pattern, "*?[") {
+		matches = []string{pattern}
+	}
+
+	var out []string
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			out = append(out, m)
+			continue
+		}
+
+		err = filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.IsDir() {
+				out = append(out, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
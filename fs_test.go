@@ -0,0 +1,37 @@
+package logmerge
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOSFsGlobZeroMatchesIsNotAnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmerge-globzero")
+	if err != nil {
+		t.Fatalf("TempDir error: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	matches, err := OSFs{}.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		t.Fatalf("Glob error: %s", err.Error())
+	}
+
+	if len(matches) != 0 {
+		t.Errorf("got %d matches, want 0: %v", len(matches), matches)
+	}
+}
+
+func TestOSFsGlobLiteralMissingPathErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmerge-globmissing")
+	if err != nil {
+		t.Fatalf("TempDir error: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := (OSFs{}).Glob(filepath.Join(dir, "missing.log")); err == nil {
+		t.Errorf("expected an error for a missing literal path")
+	}
+}
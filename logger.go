@@ -0,0 +1,26 @@
+package logmerge
+
+/*
+Logger is the logging hook MergeByOption/QuickMerge call into instead of
+writing to stdout directly. Debugf is for per-line noise, Infof for
+per-file/per-run milestones, and Warnf for recoverable errors (a line
+that failed to parse, a file that couldn't be opened) that shouldn't
+stop the merge.
+*/
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Infof(format string, args ...interface{})  {}
+func (noopLogger) Warnf(format string, args ...interface{})  {}
+
+// NewNoopLogger returns a Logger that discards everything. It's the
+// default used when Option.Logger is nil.
+func NewNoopLogger() Logger {
+	return noopLogger{}
+}
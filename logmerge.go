@@ -15,18 +15,14 @@ Example:
 	}
 
 	err := logMerge.Merge(filepath, outputPath, getTime)
-
 */
 package logmerge
 
 import (
 	"bufio"
-	"compress/gzip"
-	"container/heap"
 	"context"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"sync"
 
@@ -53,7 +49,7 @@ var (
 )
 
 /*
-	TimeHandler defined handlers for getting timestamp from each line.
+TimeHandler defined handlers for getting timestamp from each line.
 */
 type TimeHandler = func([]byte) (int64, Action, error)
 
@@ -65,30 +61,72 @@ type FilterHandler = func(string, []byte) ([]byte, Action, error)
 
 type fileReader struct {
 	filename  string
+	srcIndex  int
+	closer    io.Closer
 	scanner   *bufio.Scanner
 	timestamp int64
 	line      []byte
 	eof       bool
 	getTime   TimeHandler
 	filter    FilterHandler
+	logger    Logger
+	metrics   MetricsHandler
+
+	linesRead    int64
+	linesSkipped int64
+	linesWritten int64
+	linesDeduped int64
+	bytesIn      int64
+	bytesOut     int64
+	parseErrors  int64
+}
+
+// reportMetrics fires the metrics hook once, when the source is fully
+// drained - called from readLine on EOF.
+func (fu *fileReader) reportMetrics() {
+	if fu.metrics == nil {
+		return
+	}
+
+	fu.metrics(FileMetrics{
+		Name:         fu.filename,
+		LinesRead:    fu.linesRead,
+		LinesSkipped: fu.linesSkipped,
+		LinesWritten: fu.linesWritten,
+		LinesDeduped: fu.linesDeduped,
+		BytesIn:      fu.bytesIn,
+		BytesOut:     fu.bytesOut,
+		ParseErrors:  fu.parseErrors,
+	})
 }
 
 /*
-	Option defined some option can set for merging.
+Option defined some option can set for merging.
 */
 type Option struct {
-	SrcPath   []string        // Merge src File Path
-	DstPath   string          // The filePath merge to
-	SrcReader []io.Reader     // Src files' io.Reader
-	DstWriter io.Writer       // Destinated file's io.Writer
-	SrcGzip   bool            // Whether src file is in gzip format
-	DstGzip   bool            // Merge file in gzip format
-	DeleteSrc bool            // Delete src file
-	GetTime   TimeHandler     // The function to getTime from each line
-	Filter    FilterHandler   // The function to process each line
-	Goroutine int             // Quick merge's worker number
-	ErrChan   chan error      // Quick merge's error return
-	CTX       context.Context // Quick merge's context
+	SrcPath         []string            // Merge src File Path
+	DstPath         string              // The filePath merge to
+	SrcReader       []io.Reader         // Src files' io.Reader
+	DstWriter       io.Writer           // Destinated file's io.Writer
+	SrcGzip         bool                // Whether src file is in gzip format
+	DstGzip         bool                // Merge file in gzip format
+	SrcCodec        string              // Codec name for src files, e.g. "gzip"/"snappy"; auto-detected from extension when empty
+	DstCodec        string              // Codec name for the dst file; auto-detected from extension when empty
+	SrcFS           FS                  // Filesystem to read SrcPath from; defaults to OSFs{}
+	DstFS           FS                  // Filesystem to write DstPath to; defaults to OSFs{}
+	ArchiveFilter   func(string) bool   // Include/exclude entries by name when a SrcPath is a tar/zip archive; nil includes everything
+	DeleteSrc       bool                // Delete src file
+	GetTime         TimeHandler         // The function to getTime from each line
+	Filter          FilterHandler       // The function to process each line
+	Logger          Logger              // Where recoverable errors (bad lines; in QuickMerge also open failures) are reported; defaults to a no-op logger
+	Metrics         MetricsHandler      // Called once per source file with its counters, once fully consumed
+	TieBreak        TieBreakMode        // How to order lines whose GetTime timestamps are equal; defaults to TieBreakNone
+	TieBreakKeyFunc func([]byte) []byte // Key extractor used when TieBreak is TieBreakByKey
+	Dedup           DedupMode           // How to suppress consecutive duplicate lines; defaults to DedupNone
+	DedupKeyFunc    func([]byte) []byte // Key extractor used when Dedup is DedupByKey
+	Goroutine       int                 // Quick merge's worker number
+	ErrChan         chan error          // Quick merge's error return
+	CTX             context.Context     // Context that cancels QuickMerge or MergeByOption
 }
 
 type quickMergeJob struct {
@@ -98,17 +136,32 @@ type quickMergeJob struct {
 	filter   FilterHandler
 	errChan  chan error
 	ctx      context.Context
+	logger   Logger
+	metrics  MetricsHandler
+
+	linesRead    int64
+	linesSkipped int64
+	linesWritten int64
+	parseErrors  int64
+	bytesIn      int64
+	bytesOut     int64
 }
 
 type fileHeap struct {
-	readers []*fileReader
-	writer  *bufio.Writer
+	readers      []*fileReader
+	tieBreak     TieBreakMode
+	tieBreakFunc func([]byte) []byte
 }
 
 func (fh fileHeap) Len() int { return len(fh.readers) }
 
 func (fh fileHeap) Less(i, j int) bool {
-	return fh.readers[i].timestamp < fh.readers[j].timestamp
+	a, b := fh.readers[i], fh.readers[j]
+	if a.timestamp != b.timestamp {
+		return a.timestamp < b.timestamp
+	}
+
+	return tieBreakLess(fh.tieBreak, fh.tieBreakFunc, a, b)
 }
 
 func (fh fileHeap) Swap(i, j int) {
@@ -126,6 +179,15 @@ func (fh *fileHeap) Pop() interface{} {
 	return fr
 }
 
+// close releases fu.closer (the underlying Source.Reader), if any, and
+// is safe to call more than once.
+func (fu *fileReader) close() {
+	if fu.closer != nil {
+		fu.closer.Close()
+		fu.closer = nil
+	}
+}
+
 func (fu *fileReader) readLine() error {
 	var action Action
 	var tm int64
@@ -136,17 +198,27 @@ func (fu *fileReader) readLine() error {
 	for {
 		if ok := scanner.Scan(); !ok {
 			if err = scanner.Err(); err != nil {
+				fu.close()
 				return errors.Wrap(err, "scanner err")
 			}
 
 			// EOF
 			fu.eof = true
+			fu.close()
+			fu.reportMetrics()
 			return nil
 		}
 
+		fu.linesRead++
 		line = scanner.Bytes()
 		tm, action, err = fu.getTime(line)
 		if action == SKIP {
+			fu.linesSkipped++
+			if err != nil {
+				fu.parseErrors++
+				fu.logger.Warnf("getTime %s: %s", fu.filename, err.Error())
+			}
+
 			continue
 		} else if action == STOP {
 			return err
@@ -155,6 +227,12 @@ func (fu *fileReader) readLine() error {
 		if fu.filter != nil {
 			newline, action, err := fu.filter(fu.filename, line)
 			if action == SKIP {
+				fu.linesSkipped++
+				if err != nil {
+					fu.parseErrors++
+					fu.logger.Warnf("filter %s: %s", fu.filename, err.Error())
+				}
+
 				continue
 			} else if action == STOP {
 				return err
@@ -172,56 +250,6 @@ func (fu *fileReader) readLine() error {
 	return nil
 }
 
-func (fh *fileHeap) merge() error {
-	writer := fh.writer
-	for (*fh).Len() > 0 {
-		fr := heap.Pop(fh).(*fileReader)
-		if _, err := writer.WriteString(string(fr.line) + "\n"); err != nil {
-			return errors.Wrap(err, "writer writeString")
-		}
-
-		writer.Flush()
-
-		err := fr.readLine()
-		if err != nil {
-			return err
-		}
-
-		if !fr.eof {
-			heap.Push(fh, fr)
-		}
-	}
-
-	return nil
-}
-
-func merge(readers []*bufio.Scanner, writer *bufio.Writer, getTime TimeHandler, filter FilterHandler) error {
-	fHeap := new(fileHeap)
-
-	heap.Init(fHeap)
-
-	for _, rd := range readers {
-		fr := &fileReader{
-			scanner: rd,
-			getTime: getTime,
-			filter:  filter,
-		}
-
-		err := fr.readLine()
-		if err != nil {
-			return err
-		}
-
-		if !fr.eof {
-			heap.Push(fHeap, fr)
-		}
-	}
-
-	fHeap.writer = writer
-
-	return fHeap.merge()
-}
-
 func quickMerge(job *quickMergeJob) {
 	scanner := job.scanner
 	filter := job.filter
@@ -229,6 +257,20 @@ func quickMerge(job *quickMergeJob) {
 	filename := job.filename
 	errChan := job.errChan
 
+	defer func() {
+		if job.metrics != nil {
+			job.metrics(FileMetrics{
+				Name:         filename,
+				LinesRead:    job.linesRead,
+				LinesSkipped: job.linesSkipped,
+				LinesWritten: job.linesWritten,
+				ParseErrors:  job.parseErrors,
+				BytesIn:      job.bytesIn,
+				BytesOut:     job.bytesOut,
+			})
+		}
+	}()
+
 	for {
 		select {
 		case <-job.ctx.Done():
@@ -236,6 +278,7 @@ func quickMerge(job *quickMergeJob) {
 		default:
 			if ok := scanner.Scan(); !ok {
 				if err := scanner.Err(); err != nil {
+					job.logger.Warnf("scan %s: %s", filename, err.Error())
 					errChan <- fmt.Errorf("scan %s error %s", filename, err.Error())
 				}
 
@@ -243,12 +286,23 @@ func quickMerge(job *quickMergeJob) {
 				return
 			}
 
+			job.linesRead++
 			line := scanner.Bytes()
 			if filter != nil {
 				newline, action, err := filter(filename, line)
 				if action == SKIP {
+					job.linesSkipped++
+					if err != nil {
+						job.parseErrors++
+						job.logger.Warnf("filter %s: %s", filename, err.Error())
+					}
+
 					continue
 				} else if action == STOP {
+					if err != nil {
+						job.logger.Warnf("filter %s: %s", filename, err.Error())
+					}
+
 					errChan <- fmt.Errorf("filter: %s error %s", filename, err.Error())
 					close(writer)
 					return
@@ -262,6 +316,8 @@ func quickMerge(job *quickMergeJob) {
 			copy(resline, line)
 
 			writer <- resline
+			job.linesWritten++
+			job.bytesOut += int64(len(resline) + 1)
 		}
 	}
 }
@@ -277,41 +333,49 @@ func Merge(srcPath []string, dstPath string, getTime TimeHandler) error {
 	return MergeByOption(option)
 }
 
-// Use option to control merge behaviour.
+// Use option to control merge behaviour. This is a thin shim over the
+// Scan/Read/MergeSources pipeline: it scans option.SrcPath (literal
+// paths, globs or directories) against option.SrcFS, opens each match
+// through the codec registry, and heap-merges them into
+// option.DstPath/option.DstWriter on option.DstFS. Set option.CTX to get
+// cooperative cancellation across the whole run.
 func MergeByOption(option Option) error {
 	if option.GetTime == nil {
 		return NEED_TIMEHANDLER
 	}
 
-	var scanners []*bufio.Scanner
-	for _, fp := range option.SrcPath {
-		fd, err := os.Open(fp)
-		if err != nil {
-			return err
-		}
-
-		defer fd.Close()
+	parentCtx := option.CTX
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
 
-		var s *bufio.Scanner
-		if option.SrcGzip {
-			gzReader, err := gzip.NewReader(fd)
-			if err != nil {
-				return errors.Wrap(err, fmt.Sprintf("gzip newreader: %s ", fp))
-			}
+	// Cancelling on return wakes up Scan/Read if MergeSources stops
+	// early (STOP/error), so they don't block forever trying to send
+	// into a pipeline nobody is draining anymore.
+	ctx, cancel := context.WithCancel(parentCtx)
+	defer cancel()
 
-			defer gzReader.Close()
+	srcFS := option.SrcFS
+	if srcFS == nil {
+		srcFS = OSFs{}
+	}
 
-			s = bufio.NewScanner(gzReader)
-		} else {
-			s = bufio.NewScanner(fd)
-		}
+	dstFS := option.DstFS
+	if dstFS == nil {
+		dstFS = OSFs{}
+	}
 
-		scanners = append(scanners, s)
+	srcCodecName := option.SrcCodec
+	if srcCodecName == "" && option.SrcGzip {
+		srcCodecName = "gzip"
 	}
 
+	paths, scanErrc := Scan(ctx, srcFS, option.SrcPath...)
+	sources, readErrc := Read(ctx, srcFS, paths, srcCodecName, option.ArchiveFilter)
+
 	var dstFd = option.DstWriter
 	if dstFd == nil {
-		fd, err := os.Create(option.DstPath)
+		fd, err := dstFS.Create(option.DstPath)
 		if err != nil {
 			return err
 		}
@@ -321,26 +385,42 @@ func MergeByOption(option Option) error {
 		dstFd = fd
 	}
 
-	var writer *bufio.Writer
-
-	if option.DstGzip {
-		gzWriter := gzip.NewWriter(dstFd)
-
-		defer gzWriter.Close()
-
-		writer = bufio.NewWriter(gzWriter)
-	} else {
-		writer = bufio.NewWriter(dstFd)
+	dstCodecName := option.DstCodec
+	if dstCodecName == "" && option.DstGzip {
+		dstCodecName = "gzip"
 	}
 
-	err := merge(scanners, writer, option.GetTime, option.Filter)
+	dstCodec, err := resolveCodec(dstCodecName, option.DstPath)
 	if err != nil {
 		return err
 	}
 
+	cWriter := dstCodec.NewWriter(dstFd)
+	defer cWriter.Close()
+
+	_, mergeErrc := MergeSources(ctx, sources, cWriter, MergeOptions{
+		GetTime:         option.GetTime,
+		Filter:          option.Filter,
+		Logger:          option.Logger,
+		Metrics:         option.Metrics,
+		TieBreak:        option.TieBreak,
+		TieBreakKeyFunc: option.TieBreakKeyFunc,
+		Dedup:           option.Dedup,
+		DedupKeyFunc:    option.DedupKeyFunc,
+	})
+
+	// MergeSources has already consumed everything it's going to;
+	// cancel so a still-running Scan/Read unblocks instead of hanging
+	// on a send nobody will ever receive.
+	cancel()
+
+	if err := firstErr(scanErrc, readErrc, mergeErrc); err != nil {
+		return err
+	}
+
 	if option.DeleteSrc {
 		for _, fp := range option.SrcPath {
-			os.Remove(fp)
+			srcFS.Remove(fp)
 		}
 	}
 	return nil
@@ -362,6 +442,11 @@ func QuickMerge(option Option) error {
 		option.CTX = context.TODO()
 	}
 
+	logger := option.Logger
+	if logger == nil {
+		logger = NewNoopLogger()
+	}
+
 	finishedCount := 0
 	var mutex sync.Mutex
 	for i := 0; i < option.Goroutine; i++ {
@@ -381,44 +466,93 @@ func QuickMerge(option Option) error {
 		}()
 	}
 
+	srcFS := option.SrcFS
+	if srcFS == nil {
+		srcFS = OSFs{}
+	}
+
+	srcCodecName := option.SrcCodec
+	if srcCodecName == "" && option.SrcGzip {
+		srcCodecName = "gzip"
+	}
+
 	for _, fp := range option.SrcPath {
-		fd, err := os.Open(fp)
+		if isArchive(fp) {
+			srcs, err := openArchive(srcFS, fp, option.ArchiveFilter)
+			if err != nil {
+				logger.Warnf("open archive %s: %s", fp, err.Error())
+				option.ErrChan <- fmt.Errorf("open archive %s error: %s", fp, err.Error())
+				continue
+			}
+
+			for _, src := range srcs {
+				defer src.Reader.Close()
+
+				var job = quickMergeJob{
+					filename: src.Name,
+					writer:   writerChan,
+					filter:   option.Filter,
+					errChan:  option.ErrChan,
+					ctx:      option.CTX,
+					logger:   logger,
+					metrics:  option.Metrics,
+				}
+
+				job.scanner = bufio.NewScanner(&countingReader{r: src.Reader, counters: []*int64{&job.bytesIn}})
+
+				jobChan <- &job
+			}
+
+			continue
+		}
+
+		fd, err := srcFS.Open(fp)
 		if err != nil {
+			logger.Warnf("open %s: %s", fp, err.Error())
 			option.ErrChan <- fmt.Errorf("open %s error: %s", fp, err.Error())
 			continue
 		}
 
 		defer fd.Close()
 
-		var scanner *bufio.Scanner
-		if option.SrcGzip {
-			gzReader, err := gzip.NewReader(fd)
-			if err != nil {
-				option.ErrChan <- fmt.Errorf("gzip.NewReader error: %s", err.Error())
-				continue
-			}
-
-			defer gzReader.Close()
-
-			scanner = bufio.NewScanner(gzReader)
-		} else {
-			scanner = bufio.NewScanner(fd)
+		codec, err := resolveCodec(srcCodecName, fp)
+		if err != nil {
+			logger.Warnf("resolve codec %s: %s", fp, err.Error())
+			option.ErrChan <- fmt.Errorf("resolve codec %s error: %s", fp, err.Error())
+			continue
 		}
 
 		var job = quickMergeJob{
-			scanner:  scanner,
 			filename: filepath.Base(fp),
 			writer:   writerChan,
 			filter:   option.Filter,
 			errChan:  option.ErrChan,
 			ctx:      option.CTX,
+			logger:   logger,
+			metrics:  option.Metrics,
 		}
 
+		cReader, err := codec.NewReader(&countingReader{r: fd, counters: []*int64{&job.bytesIn}})
+		if err != nil {
+			logger.Warnf("codec newreader %s: %s", fp, err.Error())
+			option.ErrChan <- fmt.Errorf("codec.NewReader error: %s", err.Error())
+			continue
+		}
+
+		defer cReader.Close()
+
+		job.scanner = bufio.NewScanner(cReader)
+
 		jobChan <- &job
 	}
 	close(jobChan)
 
-	fd, err := os.Create(option.DstPath)
+	dstFS := option.DstFS
+	if dstFS == nil {
+		dstFS = OSFs{}
+	}
+
+	fd, err := dstFS.Create(option.DstPath)
 	if err != nil {
 		option.ErrChan <- fmt.Errorf("create dst path %s error: %s", option.DstPath, err.Error())
 		return nil
@@ -426,16 +560,22 @@ func QuickMerge(option Option) error {
 
 	defer fd.Close()
 
-	var writer *bufio.Writer
-	if option.DstGzip {
-		gzWriter := gzip.NewWriter(fd)
-		defer gzWriter.Close()
+	dstCodecName := option.DstCodec
+	if dstCodecName == "" && option.DstGzip {
+		dstCodecName = "gzip"
+	}
 
-		writer = bufio.NewWriter(gzWriter)
-	} else {
-		writer = bufio.NewWriter(fd)
+	dstCodec, err := resolveCodec(dstCodecName, option.DstPath)
+	if err != nil {
+		option.ErrChan <- fmt.Errorf("resolve dst codec error: %s", err.Error())
+		return nil
 	}
 
+	cWriter := dstCodec.NewWriter(fd)
+	defer cWriter.Close()
+
+	writer := bufio.NewWriter(cWriter)
+
 loop:
 	for {
 		select {
@@ -1,12 +1,11 @@
 package logmerge
 
 import (
-	"compress/gzip"
+	"context"
 	"errors"
-	"io"
 	"io/ioutil"
 	"os"
-	"strings"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -27,141 +26,138 @@ const (
 2020/01/18 12:24:38 [error] 176995#0: *1004136348 [lua] heartbeat.lua:107: cb_heartbeat(): failed to connect: 127.0.0.1:403, timeout, context: ngx.timer
 2020/01/18 12:31:05 [error] 177004#0: *1004144640 recv() failed (104: Connection reset by peer)
 `
-)
 
-func readFile(path string, isGzip bool) ([]byte, error) {
-	fd, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
+	base1Log = `2020/01/18 12:20:30 [error] 177003#0: *1004128358 recv() failed (104: Connection reset by peer)
+2020/01/18 12:21:55 [error] 177004#0: *1004127283 recv() failed (104: Connection reset by peer)
+2020/01/18 12:24:38 [error] 176995#0: *1004136348 [lua] heartbeat.lua:107: cb_heartbeat(): failed to connect: 127.0.0.1:403, timeout, context: ngx.timer
+2020/01/18 12:31:05 [error] 177004#0: *1004144640 recv() failed (104: Connection reset by peer)
+`
 
-	defer os.Remove(path)
-	defer fd.Close()
+	base2Log = `2020/01/18 12:20:33 [error] 177003#0: *1004128358 recv() failed (104: Connection reset by peer)
+2020/01/18 12:21:25 [error] 177004#0: *1004127283 recv() failed (104: Connection reset by peer)
+2020/01/18 12:26:38 [error] 176995#0: *1004136348 [lua] heartbeat.lua:107: cb_heartbeat(): failed to connect: 127.0.0.1:403, timeout, context: ngx.timer
+2020/01/18 12:40:05 [error] 177004#0: *1004144640 recv() failed (104: Connection reset by peer)
+`
+)
 
-	if isGzip {
-		reader, err := gzip.NewReader(fd)
-		if err != nil {
-			return nil, err
-		}
+func newBaseFS() *MemFS {
+	fsys := NewMemFS()
+	fsys.WriteFile("base1.log", []byte(base1Log))
+	fsys.WriteFile("base2.log", []byte(base2Log))
+	fsys.WriteFile("empty1.log", nil)
+	fsys.WriteFile("empty2.log", nil)
 
-		defer reader.Close()
-		return ioutil.ReadAll(reader)
-	} else {
-		return ioutil.ReadAll(fd)
-	}
+	return fsys
 }
 
-func doMerge(filePath []string, outputPath string, getTime TimeHandler) (string, error) {
-	err := Merge(filePath, outputPath, getTime)
-	if err != nil {
+func doMerge(fsys *MemFS, filePath []string, outputPath string, getTime TimeHandler) (string, error) {
+	option := Option{
+		SrcPath: filePath,
+		DstPath: outputPath,
+		SrcFS:   fsys,
+		DstFS:   fsys,
+		GetTime: getTime,
+	}
+
+	if err := MergeByOption(option); err != nil {
 		return "", err
 	}
 
-	outputFd, err := os.Open(outputPath)
+	rc, err := fsys.Open(outputPath)
 	if err != nil {
 		return "", err
 	}
-	defer os.Remove(outputPath)
-	defer outputFd.Close()
+	defer rc.Close()
 
-	outputContent, err := ioutil.ReadAll(outputFd)
+	content, err := ioutil.ReadAll(rc)
 	if err != nil {
 		return "", err
 	}
 
-	return string(outputContent), nil
+	return string(content), nil
 }
 
 func TestBaseMerge(t *testing.T) {
-	filePath := []string{"./testdata/base1.log", "./testdata/base2.log"}
-	outputPath := "./testdata/output.log"
+	fsys := newBaseFS()
+	filePath := []string{"base1.log", "base2.log"}
 
-	getTime := TimeStartHandler("2006/01/02 15:04:05")
-
-	res, err := doMerge(filePath, outputPath, getTime)
+	res, err := doMerge(fsys, filePath, "output.log", TimeStartHandler("2006/01/02 15:04:05"))
 	if err != nil {
 		t.Errorf("Merge file error: %s", err.Error())
 		return
 	}
 
-	if string(res) != EXPECTED1 {
-		t.Errorf("Different content, merge failed\n%s\n%s", string(res), EXPECTED1)
+	if res != EXPECTED1 {
+		t.Errorf("Different content, merge failed\n%s\n%s", res, EXPECTED1)
 	}
 }
 
 func TestEmptyMerge(t *testing.T) {
-	filePath := []string{"./testdata/empty1.log", "./testdata/empty2.log"}
-	outputPath := "./testdata/output.log"
-	getTime := TimeStartHandler("2006/01/02 15:04:05")
+	fsys := newBaseFS()
+	filePath := []string{"empty1.log", "empty2.log"}
 
-	res, err := doMerge(filePath, outputPath, getTime)
+	res, err := doMerge(fsys, filePath, "output.log", TimeStartHandler("2006/01/02 15:04:05"))
 	if err != nil {
 		t.Errorf("Merge file error: %s", err.Error())
 		return
 	}
 
-	expected := ""
-
-	if string(res) != expected {
-		t.Errorf("Different content, merge failed\n%s\n%s", string(res), expected)
+	if res != "" {
+		t.Errorf("Different content, merge failed\n%s\n%s", res, "")
 	}
 }
 
 func TestNilMerge(t *testing.T) {
+	fsys := newBaseFS()
 	getTime := TimeStartHandler("2006/01/02 15:04:05")
 
-	err := Merge(nil, "", getTime)
-
-	if !strings.Contains(err.Error(), "no such file or directory") {
-		t.Errorf("Merge empty file error: %s", err.Error())
+	_, err := doMerge(fsys, []string{"does-not-exist.log"}, "output.log", getTime)
+	if err == nil {
+		t.Errorf("expected error merging a missing source")
 	}
 
-	res, err := doMerge(nil, "./testdata/output.log", getTime)
+	res, err := doMerge(fsys, nil, "output.log", getTime)
 	if err != nil {
 		t.Errorf("Merge file error: %s", err.Error())
 	}
 
-	expected := ""
-	if res != expected {
-		t.Errorf("Different content, merge failed\n%s\n%s", string(res), expected)
+	if res != "" {
+		t.Errorf("Different content, merge failed\n%s\n%s", res, "")
 	}
 }
 
 func TestMixMerge(t *testing.T) {
-	filePath := []string{"./testdata/base1.log", "./testdata/empty2.log"}
-	outputPath := "./testdata/output.log"
+	fsys := newBaseFS()
+	filePath := []string{"base1.log", "empty2.log"}
 
-	getTime := TimeStartHandler("2006/01/02 15:04:05")
-
-	res, err := doMerge(filePath, outputPath, getTime)
+	res, err := doMerge(fsys, filePath, "output.log", TimeStartHandler("2006/01/02 15:04:05"))
 	if err != nil {
 		t.Errorf("Merge file error: %s", err.Error())
 		return
 	}
 
-	if string(res) != EXPECTED2 {
-		t.Errorf("Different content, merge failed\n%s\n%s", string(res), EXPECTED2)
+	if res != EXPECTED2 {
+		t.Errorf("Different content, merge failed\n%s\n%s", res, EXPECTED2)
 	}
 }
 
 func TestStopMerge(t *testing.T) {
-	filePath := []string{"./testdata/base1.log", "./testdata/empty2.log"}
-	outputPath := "./testdata/output.log"
+	fsys := newBaseFS()
+	filePath := []string{"base1.log", "empty2.log"}
 
 	gettime := func(line []byte) (int64, Action, error) {
 		return 0, STOP, errors.New("test for stop")
 	}
 
-	_, err := doMerge(filePath, outputPath, gettime)
-
-	if err.Error() != "test for stop" {
+	_, err := doMerge(fsys, filePath, "output.log", gettime)
+	if err == nil || err.Error() != "test for stop" {
 		t.Errorf("Test stopping merge error")
 	}
 }
 
 func TestMidStopMerge(t *testing.T) {
-	filePath := []string{"./testdata/base1.log", "./testdata/empty2.log"}
-	outputPath := "./testdata/output.log"
+	fsys := newBaseFS()
+	filePath := []string{"base1.log", "empty2.log"}
 
 	gettime := func(line []byte) (int64, Action, error) {
 		if string(line[:19]) == "2020/01/18 12:21:55" {
@@ -176,81 +172,85 @@ func TestMidStopMerge(t *testing.T) {
 		return tm.Unix(), NOP, nil
 	}
 
-	_, err := doMerge(filePath, outputPath, gettime)
-	if err.Error() != "test for stop" {
+	_, err := doMerge(fsys, filePath, "output.log", gettime)
+	if err == nil || err.Error() != "test for stop" {
 		t.Errorf("Test stopping merge error")
 	}
 
-	outputFd, err := os.Open(outputPath)
+	rc, err := fsys.Open("output.log")
 	if err != nil {
-		t.Errorf("Open outputPath error: %s", err.Error())
+		t.Errorf("Open output.log error: %s", err.Error())
 		return
 	}
-	defer os.Remove(outputPath)
-	defer outputFd.Close()
+	defer rc.Close()
 
-	outputContent, err := ioutil.ReadAll(outputFd)
+	content, err := ioutil.ReadAll(rc)
 	if err != nil {
-		t.Errorf("Read output fd error: %s", err.Error())
+		t.Errorf("Read output.log error: %s", err.Error())
 		return
 	}
 
 	expected := `2020/01/18 12:20:30 [error] 177003#0: *1004128358 recv() failed (104: Connection reset by peer)
 `
 
-	if string(outputContent) != expected {
+	if string(content) != expected {
 		t.Errorf("Different content, merge failed")
 	}
 }
 
 func TestGzipMerge(t *testing.T) {
-	filePath := []string{"./testdata/base1.log.gz", "./testdata/base2.log.gz"}
-	dstPath := "./testdata/output.log"
-
+	fsys := newBaseFS()
+	filePath := []string{"base1.log.gz", "base2.log.gz"}
 	getTime := TimeStartHandler("2006/01/02 15:04:05")
-	err := MergeByOption(Option{SrcPath: filePath, DstPath: dstPath,
-		SrcGzip: true, GetTime: getTime})
+
+	for name, content := range map[string]string{"base1.log.gz": base1Log, "base2.log.gz": base2Log} {
+		var buf bufferWriteCloser
+		w := gzipCodec{}.NewWriter(&buf)
+		w.Write([]byte(content))
+		w.Close()
+		fsys.WriteFile(name, buf.Bytes())
+	}
+
+	err := MergeByOption(Option{SrcPath: filePath, DstPath: "output.log",
+		SrcFS: fsys, DstFS: fsys, GetTime: getTime})
 	if err != nil {
 		t.Errorf("Merge file error: %s", err.Error())
 	}
-	dstFd, err := os.Open(dstPath)
+
+	rc, err := fsys.Open("output.log")
 	if err != nil {
-		t.Errorf("Open dstPath error: %s", err.Error())
+		t.Errorf("Open output.log error: %s", err.Error())
 	}
-	defer dstFd.Close()
+	defer rc.Close()
 
-	res, err := ioutil.ReadAll(dstFd)
+	res, err := ioutil.ReadAll(rc)
 	if err != nil {
-		t.Errorf("read dstfd error: %s", err.Error())
+		t.Errorf("read output.log error: %s", err.Error())
 	}
 
 	if string(res) != EXPECTED1 {
 		t.Errorf("Different content, merge failed\n%s\n%s", string(res), EXPECTED1)
 	}
 
-	os.Remove(dstPath)
-
-	dstPath = "./testdata/output.log.gz"
-	err = MergeByOption(Option{SrcPath: filePath, DstPath: dstPath,
-		SrcGzip: true, DstGzip: true, GetTime: getTime})
+	err = MergeByOption(Option{SrcPath: filePath, DstPath: "output.log.gz",
+		SrcFS: fsys, DstFS: fsys, DstGzip: true, GetTime: getTime})
 	if err != nil {
 		t.Errorf("Merge file error: %s", err.Error())
 	}
 
-	dstFd, err = os.Open(dstPath)
+	rc, err = fsys.Open("output.log.gz")
 	if err != nil {
-		t.Errorf("Open dstPath error: %s", err.Error())
+		t.Errorf("Open output.log.gz error: %s", err.Error())
 	}
+	defer rc.Close()
 
-	defer dstFd.Close()
-
-	reader, err := gzip.NewReader(dstFd)
+	gzReader, err := gzipCodec{}.NewReader(rc)
 	if err != nil {
 		t.Errorf("gzip NewReader error: %s", err.Error())
 	}
-	defer reader.Close()
+	defer gzReader.Close()
 
-	res, err = ioutil.ReadAll(reader)
+	res, err = ioutil.ReadAll(gzReader)
 	if err != nil {
 		t.Errorf("Merge file error: %s", err.Error())
 	}
@@ -258,45 +258,19 @@ func TestGzipMerge(t *testing.T) {
 	if string(res) != EXPECTED1 {
 		t.Errorf("Different content, merge failed\n%s\n%s", string(res), EXPECTED1)
 	}
-
-	os.Remove(dstPath)
-
 }
 
 func TestDeleteSrcMerge(t *testing.T) {
-	filePath := []string{"./testdata/base1.log", "./testdata/base2.log"}
-
-	// prepare
-	var copyPath []string
-	for _, fp := range filePath {
-		cp := fp + "_copy"
-		copyFd, err := os.Create(cp)
-		if err != nil {
-			t.Errorf("create %s error: %s", cp, err.Error())
-		}
-
-		fd, err := os.Open(fp)
-		if err != nil {
-			t.Errorf("create %s error: %s", cp, err.Error())
-		}
-
-		_, err = io.Copy(copyFd, fd)
-		if err != nil {
-			t.Errorf("Create copyPath: %s", err.Error())
-			return
-		}
-
-		copyFd.Close()
-		fd.Close()
-		copyPath = append(copyPath, cp)
-	}
-	outputPath := "./testdata/output.log"
+	fsys := newBaseFS()
+	filePath := []string{"base1.log", "base2.log"}
 
 	getTime := TimeStartHandler("2006/01/02 15:04:05")
 
 	option := Option{
-		SrcPath:   copyPath,
-		DstPath:   outputPath,
+		SrcPath:   filePath,
+		DstPath:   "output.log",
+		SrcFS:     fsys,
+		DstFS:     fsys,
 		DeleteSrc: true,
 		GetTime:   getTime,
 	}
@@ -307,20 +281,165 @@ func TestDeleteSrcMerge(t *testing.T) {
 		return
 	}
 
-	res, err := readFile(outputPath, false)
+	rc, err := fsys.Open("output.log")
+	if err != nil {
+		t.Errorf("Open output.log error: %s", err.Error())
+	}
+	defer rc.Close()
+
+	res, err := ioutil.ReadAll(rc)
 	if err != nil {
-		t.Errorf("readFile error: %s", err.Error())
+		t.Errorf("read output.log error: %s", err.Error())
 	}
 
 	if string(res) != EXPECTED1 {
 		t.Errorf("Different content, merge failed\n%s\nexpected:\n%s", string(res), EXPECTED1)
 	}
 
-	for _, copyFp := range copyPath {
-		fi, _ := os.Stat(copyFp)
-
-		if fi != nil {
-			t.Errorf("file exist")
+	for _, fp := range filePath {
+		if _, err := fsys.Open(fp); err == nil {
+			t.Errorf("file %s still exists", fp)
 		}
 	}
 }
+
+func TestCrossFilesystemMerge(t *testing.T) {
+	srcFS := newBaseFS()
+	dstFS := NewMemFS()
+
+	option := Option{
+		SrcPath: []string{"base1.log", "base2.log"},
+		DstPath: "output.log",
+		SrcFS:   srcFS,
+		DstFS:   dstFS,
+		GetTime: TimeStartHandler("2006/01/02 15:04:05"),
+	}
+
+	if err := MergeByOption(option); err != nil {
+		t.Errorf("Merge file error: %s", err.Error())
+		return
+	}
+
+	if _, err := srcFS.Open("output.log"); err == nil {
+		t.Errorf("output.log should not exist on srcFS")
+	}
+
+	rc, err := dstFS.Open("output.log")
+	if err != nil {
+		t.Errorf("Open output.log on dstFS error: %s", err.Error())
+		return
+	}
+	defer rc.Close()
+
+	res, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Errorf("read output.log error: %s", err.Error())
+	}
+
+	if string(res) != EXPECTED1 {
+		t.Errorf("Different content, merge failed\n%s\n%s", string(res), EXPECTED1)
+	}
+}
+
+func TestQuickMergeExpandsArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmerge-quickmerge-archive")
+	if err != nil {
+		t.Fatalf("TempDir error: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	data := buildTarGz(t, map[string]string{
+		"a.log": "one\n",
+		"b.log": "two\n",
+	})
+
+	archivePath := filepath.Join(dir, "logs.tar.gz")
+	if err := ioutil.WriteFile(archivePath, data, 0o644); err != nil {
+		t.Fatalf("write archive error: %s", err.Error())
+	}
+
+	dstPath := filepath.Join(dir, "output.log")
+
+	errChan := make(chan error, 8)
+	option := Option{
+		SrcPath:   []string{archivePath},
+		DstPath:   dstPath,
+		Goroutine: 1,
+		ErrChan:   errChan,
+		CTX:       context.Background(),
+	}
+
+	if err := QuickMerge(option); err != nil {
+		t.Fatalf("QuickMerge error: %s", err.Error())
+	}
+
+	for err := range errChan {
+		t.Errorf("QuickMerge reported error: %s", err.Error())
+	}
+
+	res, err := ioutil.ReadFile(dstPath)
+	if err != nil {
+		t.Fatalf("read output.log error: %s", err.Error())
+	}
+
+	got := string(res)
+	if got != "one\ntwo\n" && got != "two\none\n" {
+		t.Errorf("got %q, want lines \"one\\n\" and \"two\\n\" in either order", got)
+	}
+}
+
+func TestQuickMergeHonorsSrcFSAndDstFS(t *testing.T) {
+	fsys := NewMemFS()
+	fsys.WriteFile("a.log", []byte("one\n"))
+	fsys.WriteFile("b.log", []byte("two\n"))
+
+	errChan := make(chan error, 8)
+	option := Option{
+		SrcPath:   []string{"a.log", "b.log"},
+		DstPath:   "output.log",
+		SrcFS:     fsys,
+		DstFS:     fsys,
+		Goroutine: 1,
+		ErrChan:   errChan,
+		CTX:       context.Background(),
+	}
+
+	if err := QuickMerge(option); err != nil {
+		t.Fatalf("QuickMerge error: %s", err.Error())
+	}
+
+	for err := range errChan {
+		t.Errorf("QuickMerge reported error: %s", err.Error())
+	}
+
+	rc, err := fsys.Open("output.log")
+	if err != nil {
+		t.Fatalf("open output.log on MemFS error: %s", err.Error())
+	}
+	defer rc.Close()
+
+	res, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read output.log error: %s", err.Error())
+	}
+
+	got := string(res)
+	if got != "one\ntwo\n" && got != "two\none\n" {
+		t.Errorf("got %q, want lines \"one\\n\" and \"two\\n\" in either order", got)
+	}
+}
+
+// bufferWriteCloser lets gzipCodec.NewWriter target an in-memory buffer
+// when seeding a *MemFS with gzip-compressed fixtures.
+type bufferWriteCloser struct {
+	data []byte
+}
+
+func (b *bufferWriteCloser) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+func (b *bufferWriteCloser) Bytes() []byte {
+	return b.data
+}
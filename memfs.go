@@ -0,0 +1,114 @@
+package logmerge
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+MemFS is an in-memory FS. It exists mainly for tests and fixtures
+that shouldn't touch the real filesystem, but it's a plain FS like
+any other - callers can use it to merge from fixtures built in
+memory too.
+*/
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemFS returns an empty MemFS.
+func NewMemFS() *MemFS {
+	return &MemFS{files: map[string][]byte{}}
+}
+
+// WriteFile seeds name with data, as if it had already been written to
+// disk - the usual way to populate a MemFS before merging.
+func (m *MemFS) WriteFile(name string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cp := make([]byte, len(data))
+	copy(cp, data)
+	m.files[name] = cp
+}
+
+func (m *MemFS) Open(name string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	data, ok := m.files[name]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *MemFS) Create(name string) (io.WriteCloser, error) {
+	return &memFile{fs: m, name: name}, nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.files[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+
+	delete(m.files, name)
+	return nil
+}
+
+func (m *MemFS) Glob(pattern string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prefix := strings.TrimSuffix(pattern, "/") + "/"
+
+	var out []string
+	for name := range m.files {
+		ok, err := filepath.Match(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok || strings.HasPrefix(name, prefix) {
+			out = append(out, name)
+		}
+	}
+
+	sort.Strings(out)
+
+	// A pattern with no glob metacharacters names a single file, so a
+	// miss means it doesn't exist - mirrors OSFs.Glob, which stats the
+	// literal path and errors the same way.
+	if len(out) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		return nil, &os.PathError{Op: "open", Path: pattern, Err: os.ErrNotExist}
+	}
+
+	return out, nil
+}
+
+// memFile buffers writes until Close, then commits them to the owning
+// MemFS - mirroring how os.File only becomes visible once flushed.
+type memFile struct {
+	fs   *MemFS
+	name string
+	buf  bytes.Buffer
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	return f.buf.Write(p)
+}
+
+func (f *memFile) Close() error {
+	f.fs.WriteFile(f.name, f.buf.Bytes())
+	return nil
+}
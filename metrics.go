@@ -0,0 +1,20 @@
+package logmerge
+
+/*
+FileMetrics reports per-file counters once a source has been fully
+consumed, for callers that want visibility into a merge without
+instrumenting MergeByOption/QuickMerge themselves.
+*/
+type FileMetrics struct {
+	Name         string // the Source name, e.g. "a.log" or "a.tar.gz:inner.log"
+	LinesRead    int64  // lines read from the source, including skipped ones
+	LinesSkipped int64  // lines skipped by GetTime/Filter
+	LinesWritten int64  // lines actually written to the destination
+	LinesDeduped int64  // lines suppressed by Option.Dedup as duplicates
+	BytesIn      int64  // bytes read from the source
+	BytesOut     int64  // bytes written to the destination attributable to this source
+	ParseErrors  int64  // lines skipped because GetTime/Filter returned an error
+}
+
+// MetricsHandler receives a FileMetrics once its source is fully drained.
+type MetricsHandler = func(FileMetrics)
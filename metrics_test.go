@@ -0,0 +1,89 @@
+package logmerge
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	warns []string
+}
+
+func (r *recordingLogger) Debugf(format string, args ...interface{}) {}
+func (r *recordingLogger) Infof(format string, args ...interface{})  {}
+
+func (r *recordingLogger) Warnf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warns = append(r.warns, fmt.Sprintf(format, args...))
+}
+
+func TestMergeByOptionReportsParseErrorsToLoggerAndMetrics(t *testing.T) {
+	fsys := NewMemFS()
+	fsys.WriteFile("a.log", []byte("not-a-timestamp line\n2020/01/01 00:00:01 ok\n"))
+
+	logger := &recordingLogger{}
+
+	var metrics []FileMetrics
+	var mu sync.Mutex
+
+	err := MergeByOption(Option{
+		SrcPath: []string{"a.log"},
+		DstPath: "out.log",
+		SrcFS:   fsys,
+		DstFS:   fsys,
+		GetTime: TimeStartHandler("2006/01/02 15:04:05"),
+		Logger:  logger,
+		Metrics: func(m FileMetrics) {
+			mu.Lock()
+			defer mu.Unlock()
+			metrics = append(metrics, m)
+		},
+	})
+	if err != nil {
+		t.Fatalf("MergeByOption error: %s", err.Error())
+	}
+
+	if len(logger.warns) != 1 {
+		t.Fatalf("got %d warnings, want 1: %v", len(logger.warns), logger.warns)
+	}
+
+	if len(metrics) != 1 {
+		t.Fatalf("got %d FileMetrics reports, want 1", len(metrics))
+	}
+
+	m := metrics[0]
+	if m.Name != "a.log" {
+		t.Errorf("got Name %q, want %q", m.Name, "a.log")
+	}
+	if m.LinesRead != 2 {
+		t.Errorf("got LinesRead %d, want 2", m.LinesRead)
+	}
+	if m.LinesSkipped != 1 {
+		t.Errorf("got LinesSkipped %d, want 1", m.LinesSkipped)
+	}
+	if m.LinesWritten != 1 {
+		t.Errorf("got LinesWritten %d, want 1", m.LinesWritten)
+	}
+	if m.ParseErrors != 1 {
+		t.Errorf("got ParseErrors %d, want 1", m.ParseErrors)
+	}
+}
+
+func TestMergeByOptionDefaultsToNoopLogger(t *testing.T) {
+	fsys := NewMemFS()
+	fsys.WriteFile("a.log", []byte("not-a-timestamp line\n2020/01/01 00:00:01 ok\n"))
+
+	err := MergeByOption(Option{
+		SrcPath: []string{"a.log"},
+		DstPath: "out.log",
+		SrcFS:   fsys,
+		DstFS:   fsys,
+		GetTime: TimeStartHandler("2006/01/02 15:04:05"),
+	})
+	if err != nil {
+		t.Fatalf("MergeByOption error: %s", err.Error())
+	}
+}
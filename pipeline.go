@@ -0,0 +1,344 @@
+package logmerge
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Report summarizes a MergeSources run: how much data and how many
+files went through the pipeline, for callers that want metrics
+without instrumenting the merge loop themselves.
+*/
+type Report struct {
+	TotalBytes   int64 // bytes read across every source
+	LinesWritten int64 // lines written to the destination
+	FilesMerged  int   // sources that contributed at least one line
+	Skipped      int   // sources that were empty (EOF on the first read)
+}
+
+/*
+Source is a single opened merge input, produced by Read and consumed
+by MergeSources. Name is carried through to FilterHandler so filters
+can tell which file a line came from.
+*/
+type Source struct {
+	Name   string
+	Reader io.ReadCloser
+}
+
+// Scan expands roots - literal paths, glob patterns, or directories -
+// against fsys and emits every matching file path on the returned
+// channel. It honors ctx.Done() on every send so a cancelled merge
+// stops scanning promptly.
+func Scan(ctx context.Context, fsys FS, roots ...string) (<-chan string, <-chan error) {
+	out := make(chan string)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer close(errc)
+
+		for _, root := range roots {
+			matches, err := fsys.Glob(root)
+			if err != nil {
+				errc <- errors.Wrap(err, fmt.Sprintf("glob %s", root))
+				return
+			}
+
+			for _, m := range matches {
+				select {
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				case out <- m:
+				}
+			}
+		}
+	}()
+
+	return out, errc
+}
+
+// Read opens each path received from paths against fsys using the
+// codec registry (codecName wins, otherwise the codec is auto-detected
+// from the file extension) and emits one *Source per file. A tar or zip
+// archive is expanded into one Source per entry passing archiveFilter
+// (nil includes every entry) instead of a single Source for the whole
+// archive. It honors ctx.Done() on every send/receive.
+func Read(ctx context.Context, fsys FS, paths <-chan string, codecName string, archiveFilter func(string) bool) (<-chan *Source, <-chan error) {
+	out := make(chan *Source)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer close(errc)
+
+		for {
+			select {
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			case path, ok := <-paths:
+				if !ok {
+					return
+				}
+
+				var srcs []*Source
+				if isArchive(path) {
+					var err error
+					srcs, err = openArchive(fsys, path, archiveFilter)
+					if err != nil {
+						errc <- err
+						return
+					}
+				} else {
+					src, err := openSource(fsys, path, codecName)
+					if err != nil {
+						errc <- err
+						return
+					}
+
+					srcs = []*Source{src}
+				}
+
+				for _, src := range srcs {
+					select {
+					case <-ctx.Done():
+						src.Reader.Close()
+						errc <- ctx.Err()
+						return
+					case out <- src:
+					}
+				}
+			}
+		}
+	}()
+
+	return out, errc
+}
+
+func openSource(fsys FS, path, codecName string) (*Source, error) {
+	fd, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := resolveCodec(codecName, path)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	cReader, err := codec.NewReader(fd)
+	if err != nil {
+		fd.Close()
+		return nil, errors.Wrap(err, fmt.Sprintf("codec newreader: %s", path))
+	}
+
+	return &Source{Name: path, Reader: multiCloser{Reader: cReader, closers: []io.Closer{cReader, fd}}}, nil
+}
+
+// multiCloser reads from Reader and closes every closer in order on
+// Close, so a codec reader (e.g. gzip.Reader) and the file it wraps are
+// both released.
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m multiCloser) Close() error {
+	var firstErr error
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+type countingReader struct {
+	r        io.Reader
+	counters []*int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	for _, ctr := range c.counters {
+		*ctr += int64(n)
+	}
+
+	return n, err
+}
+
+/*
+MergeOptions groups the non-plumbing knobs MergeSources needs, so its
+signature doesn't grow a parameter for every feature.
+*/
+type MergeOptions struct {
+	GetTime         TimeHandler
+	Filter          FilterHandler
+	Logger          Logger
+	Metrics         MetricsHandler
+	TieBreak        TieBreakMode
+	TieBreakKeyFunc func([]byte) []byte
+	Dedup           DedupMode
+	DedupKeyFunc    func([]byte) []byte
+}
+
+// MergeSources performs the k-way heap merge over sources and writes the
+// result to w, honoring ctx.Done() at every step so a cancelled merge
+// unwinds instead of running to completion. opts.Logger receives
+// recoverable per-line errors (nil uses a no-op logger); opts.Metrics,
+// if non-nil, is called once per source once it's fully drained. It
+// returns a *Report and an error channel the caller can select on
+// alongside ctx.Done().
+func MergeSources(ctx context.Context, sources <-chan *Source, w io.Writer, opts MergeOptions) (*Report, <-chan error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = NewNoopLogger()
+	}
+
+	report := &Report{}
+	errc := make(chan error, 1)
+	fail := func(err error) (*Report, <-chan error) {
+		errc <- err
+		close(errc)
+		return report, errc
+	}
+
+	fHeap := &fileHeap{tieBreak: opts.TieBreak, tieBreakFunc: opts.TieBreakKeyFunc}
+	heap.Init(fHeap)
+
+	writer := bufio.NewWriter(w)
+
+	srcIndex := 0
+
+collect:
+	for {
+		select {
+		case <-ctx.Done():
+			return fail(ctx.Err())
+		case src, ok := <-sources:
+			if !ok {
+				break collect
+			}
+
+			fr := &fileReader{
+				filename: src.Name,
+				srcIndex: srcIndex,
+				closer:   src.Reader,
+				getTime:  opts.GetTime,
+				filter:   opts.Filter,
+				logger:   logger,
+				metrics:  opts.Metrics,
+			}
+			srcIndex++
+			fr.scanner = bufio.NewScanner(&countingReader{r: src.Reader, counters: []*int64{&report.TotalBytes, &fr.bytesIn}})
+
+			// readLine closes fr.closer itself once the source hits
+			// EOF or a scanner error, so no explicit Close here; a
+			// STOP from GetTime/Filter leaves it open.
+			if err := fr.readLine(); err != nil {
+				return fail(err)
+			}
+
+			if fr.eof {
+				report.Skipped++
+				continue
+			}
+
+			report.FilesMerged++
+			heap.Push(fHeap, fr)
+		}
+	}
+
+	var lastKey []byte
+	haveLastKey := false
+
+	for fHeap.Len() > 0 {
+		select {
+		case <-ctx.Done():
+			return fail(ctx.Err())
+		default:
+		}
+
+		fr := heap.Pop(fHeap).(*fileReader)
+
+		key, dedupes := dedupKey(opts.Dedup, opts.DedupKeyFunc, fr.line)
+		skip := dedupes && haveLastKey && bytes.Equal(key, lastKey)
+		if dedupes {
+			// Copy: key may alias fr.line, which the upcoming
+			// fr.readLine() can overwrite via the scanner's reused
+			// buffer before the next iteration compares against it.
+			lastKey = append([]byte(nil), key...)
+			haveLastKey = true
+		}
+
+		if skip {
+			fr.linesDeduped++
+		} else {
+			n, err := writer.WriteString(string(fr.line) + "\n")
+			if err != nil {
+				return fail(errors.Wrap(err, "writer writeString"))
+			}
+
+			writer.Flush()
+			report.LinesWritten++
+			fr.linesWritten++
+			fr.bytesOut += int64(n)
+		}
+
+		if err := fr.readLine(); err != nil {
+			return fail(err)
+		}
+
+		if !fr.eof {
+			heap.Push(fHeap, fr)
+		}
+	}
+
+	close(errc)
+	return report, errc
+}
+
+// firstErr drains every channel to completion and returns the first
+// non-nil error seen, so callers composing Scan/Read/MergeSources can
+// report whichever stage failed first. Cancellation errors are held
+// back behind any other error: once one stage fails, the caller cancels
+// ctx to unblock the rest of the pipeline, and those resulting
+// context.Canceled errors shouldn't mask the real cause.
+func firstErr(chans ...<-chan error) error {
+	var err, cancelErr error
+	for _, c := range chans {
+		e := <-c
+		if e == nil {
+			continue
+		}
+
+		if stderrors.Is(e, context.Canceled) || stderrors.Is(e, context.DeadlineExceeded) {
+			if cancelErr == nil {
+				cancelErr = e
+			}
+			continue
+		}
+
+		if err == nil {
+			err = e
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return cancelErr
+}
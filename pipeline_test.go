@@ -0,0 +1,185 @@
+package logmerge
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTempFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %s", path, err.Error())
+	}
+
+	return path
+}
+
+func TestScanGlobAndDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmerge-scan")
+	if err != nil {
+		t.Fatalf("TempDir error: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	writeTempFile(t, dir, "a.log", "a")
+	writeTempFile(t, dir, "b.log", "b")
+
+	paths, errc := Scan(context.Background(), OSFs{}, filepath.Join(dir, "*.log"))
+
+	var got []string
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	if err := <-errc; err != nil {
+		t.Fatalf("Scan error: %s", err.Error())
+	}
+
+	if len(got) != 2 {
+		t.Errorf("got %d paths, want 2: %v", len(got), got)
+	}
+
+	paths, errc = Scan(context.Background(), OSFs{}, dir)
+	got = got[:0]
+	for p := range paths {
+		got = append(got, p)
+	}
+
+	if err := <-errc; err != nil {
+		t.Fatalf("Scan dir error: %s", err.Error())
+	}
+
+	if len(got) != 2 {
+		t.Errorf("got %d paths scanning dir, want 2: %v", len(got), got)
+	}
+}
+
+func TestReadOpensWithNoneCodec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmerge-read")
+	if err != nil {
+		t.Fatalf("TempDir error: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeTempFile(t, dir, "a.log", "hello\n")
+
+	paths := make(chan string, 1)
+	paths <- path
+	close(paths)
+
+	sources, errc := Read(context.Background(), OSFs{}, paths, "", nil)
+
+	src, ok := <-sources
+	if !ok {
+		t.Fatal("expected a source")
+	}
+	defer src.Reader.Close()
+
+	if src.Name != path {
+		t.Errorf("got name %q, want %q", src.Name, path)
+	}
+
+	if err := <-errc; err != nil {
+		t.Errorf("Read error: %s", err.Error())
+	}
+}
+
+func TestMergeSourcesReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmerge-merge")
+	if err != nil {
+		t.Fatalf("TempDir error: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	writeTempFile(t, dir, "1.log", "2020/01/01 00:00:01 one\n")
+	writeTempFile(t, dir, "2.log", "2020/01/01 00:00:02 two\n")
+
+	ctx := context.Background()
+	paths, _ := Scan(ctx, OSFs{}, filepath.Join(dir, "*.log"))
+	sources, _ := Read(ctx, OSFs{}, paths, "", nil)
+
+	var out bytes.Buffer
+	getTime := TimeStartHandler("2006/01/02 15:04:05")
+
+	report, errc := MergeSources(ctx, sources, &out, MergeOptions{GetTime: getTime})
+	if err := <-errc; err != nil {
+		t.Fatalf("MergeSources error: %s", err.Error())
+	}
+
+	want := "2020/01/01 00:00:01 one\n2020/01/01 00:00:02 two\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	if report.FilesMerged != 2 {
+		t.Errorf("got FilesMerged %d, want 2", report.FilesMerged)
+	}
+
+	if report.LinesWritten != 2 {
+		t.Errorf("got LinesWritten %d, want 2", report.LinesWritten)
+	}
+
+	if report.TotalBytes == 0 {
+		t.Errorf("expected TotalBytes to be counted")
+	}
+}
+
+// closeTrackingReader wraps a Reader so a test can observe whether
+// Close was called on it.
+type closeTrackingReader struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTrackingReader) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestMergeSourcesClosesDrainedReaders(t *testing.T) {
+	ctx := context.Background()
+
+	sources := make(chan *Source, 2)
+	a := &closeTrackingReader{Reader: strings.NewReader("2020/01/01 00:00:01 one\n")}
+	b := &closeTrackingReader{Reader: strings.NewReader("2020/01/01 00:00:02 two\n")}
+	sources <- &Source{Name: "a.log", Reader: a}
+	sources <- &Source{Name: "b.log", Reader: b}
+	close(sources)
+
+	var out bytes.Buffer
+	getTime := TimeStartHandler("2006/01/02 15:04:05")
+
+	_, errc := MergeSources(ctx, sources, &out, MergeOptions{GetTime: getTime})
+	if err := <-errc; err != nil {
+		t.Fatalf("MergeSources error: %s", err.Error())
+	}
+
+	if !a.closed {
+		t.Errorf("expected a.log's reader to be closed once drained")
+	}
+
+	if !b.closed {
+		t.Errorf("expected b.log's reader to be closed once drained")
+	}
+}
+
+func TestMergeSourcesCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sources := make(chan *Source)
+
+	_, errc := MergeSources(ctx, sources, &bytes.Buffer{}, MergeOptions{GetTime: TimeStartHandler(time.RFC3339)})
+	if err := <-errc; err == nil {
+		t.Errorf("expected cancellation error")
+	}
+}
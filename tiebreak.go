@@ -0,0 +1,78 @@
+package logmerge
+
+import "bytes"
+
+/*
+TieBreakMode controls how the heap merge orders lines whose GetTime
+timestamps are equal. The default, TieBreakNone, leaves ties in
+whatever order container/heap happens to pop them in, which is not
+guaranteed to be stable across runs.
+*/
+type TieBreakMode int
+
+const (
+	// TieBreakNone leaves equal-timestamp lines in heap-pop order.
+	TieBreakNone TieBreakMode = iota
+	// TieBreakBySource orders equal-timestamp lines by the index of
+	// their source among Option.SrcPath, so lines from the first
+	// source always come before lines from later ones.
+	TieBreakBySource
+	// TieBreakByBytes orders equal-timestamp lines lexicographically
+	// by their raw bytes.
+	TieBreakByBytes
+	// TieBreakByKey orders equal-timestamp lines lexicographically by
+	// the key Option.TieBreakKeyFunc extracts from each line.
+	TieBreakByKey
+)
+
+// tieBreakLess reports whether a should sort before b among lines that
+// share a timestamp, according to mode (and keyFunc, when mode is
+// TieBreakByKey).
+func tieBreakLess(mode TieBreakMode, keyFunc func([]byte) []byte, a, b *fileReader) bool {
+	switch mode {
+	case TieBreakBySource:
+		return a.srcIndex < b.srcIndex
+	case TieBreakByBytes:
+		return bytes.Compare(a.line, b.line) < 0
+	case TieBreakByKey:
+		if keyFunc == nil {
+			return false
+		}
+		return bytes.Compare(keyFunc(a.line), keyFunc(b.line)) < 0
+	default:
+		return false
+	}
+}
+
+/*
+DedupMode controls how the heap merge suppresses consecutive duplicate
+lines in its output.
+*/
+type DedupMode int
+
+const (
+	// DedupNone writes every line, duplicates included.
+	DedupNone DedupMode = iota
+	// DedupExact skips a line that's byte-for-byte identical to the
+	// previous line written.
+	DedupExact
+	// DedupByKey skips a line whose Option.DedupKeyFunc key matches
+	// the previous line written's key.
+	DedupByKey
+)
+
+// dedupKey returns the key used to compare line against the
+// previously-written line under mode, and whether dedup applies at all.
+func dedupKey(mode DedupMode, keyFunc func([]byte) []byte, line []byte) ([]byte, bool) {
+	switch mode {
+	case DedupExact:
+		return line, true
+	case DedupByKey:
+		if keyFunc == nil {
+			return nil, false
+		}
+		return keyFunc(line), true
+	default:
+		return nil, false
+	}
+}
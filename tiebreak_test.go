@@ -0,0 +1,74 @@
+package logmerge
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeSourcesTieBreakBySource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmerge-tiebreak")
+	if err != nil {
+		t.Fatalf("TempDir error: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	writeTempFile(t, dir, "1.log", "2020/01/01 00:00:00 first\n")
+	writeTempFile(t, dir, "2.log", "2020/01/01 00:00:00 second\n")
+
+	ctx := context.Background()
+	paths, _ := Scan(ctx, OSFs{}, filepath.Join(dir, "*.log"))
+	sources, _ := Read(ctx, OSFs{}, paths, "", nil)
+
+	var out bytes.Buffer
+	getTime := TimeStartHandler("2006/01/02 15:04:05")
+
+	_, errc := MergeSources(ctx, sources, &out, MergeOptions{GetTime: getTime, TieBreak: TieBreakBySource})
+	if err := <-errc; err != nil {
+		t.Fatalf("MergeSources error: %s", err.Error())
+	}
+
+	want := "2020/01/01 00:00:00 first\n2020/01/01 00:00:00 second\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestMergeSourcesDedupExact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logmerge-dedup")
+	if err != nil {
+		t.Fatalf("TempDir error: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	writeTempFile(t, dir, "1.log", "2020/01/01 00:00:00 dup\n2020/01/01 00:00:02 one\n")
+	writeTempFile(t, dir, "2.log", "2020/01/01 00:00:00 dup\n")
+
+	ctx := context.Background()
+	paths, _ := Scan(ctx, OSFs{}, filepath.Join(dir, "*.log"))
+	sources, _ := Read(ctx, OSFs{}, paths, "", nil)
+
+	var out bytes.Buffer
+	getTime := TimeStartHandler("2006/01/02 15:04:05")
+
+	report, errc := MergeSources(ctx, sources, &out, MergeOptions{
+		GetTime:  getTime,
+		TieBreak: TieBreakBySource,
+		Dedup:    DedupExact,
+	})
+	if err := <-errc; err != nil {
+		t.Fatalf("MergeSources error: %s", err.Error())
+	}
+
+	want := "2020/01/01 00:00:00 dup\n2020/01/01 00:00:02 one\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	if report.LinesWritten != 2 {
+		t.Errorf("got LinesWritten %d, want 2", report.LinesWritten)
+	}
+}
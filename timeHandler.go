@@ -1,12 +1,14 @@
 package logmerge
 
 import (
-	"fmt"
 	"time"
 )
 
 /*
-	Easy way to get timehandler to deal with logs starting with date.
+Easy way to get timehandler to deal with logs starting with date. A
+line that's too short or fails to parse is skipped rather than failing
+the whole merge; the parse error is still returned alongside SKIP so
+callers using Option.Logger see it.
 */
 func TimeStartHandler(layout string) TimeHandler {
 	f := func(line []byte) (int64, Action, error) {
@@ -16,8 +18,7 @@ func TimeStartHandler(layout string) TimeHandler {
 
 		tm, err := time.Parse(layout, string(line[:len(layout)]))
 		if err != nil {
-			fmt.Printf("err: %s\n", err.Error())
-			return 0, SKIP, nil
+			return 0, SKIP, err
 		}
 
 		return tm.Unix(), NOP, nil